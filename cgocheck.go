@@ -0,0 +1,149 @@
+package coffer
+
+import os "os"
+import "unsafe"
+import "runtime"
+import "sync"
+import "sync/atomic"
+import "strconv"
+
+// #include <stdlib.h>
+// #include <string.h>
+import "C"
+
+// Returned by Write() (or via WriteRaw()'s caller doing its own check)
+// when the cgocheck validator finds what looks like a Go pointer inside
+// a payload that is about to be memmove'd into C-owned memory.
+var ErrGoPointerInC = os.NewError("coffer: Go pointer found in payload written to C memory")
+
+// Current validation level, changed via SetCgoCheck()
+//
+// 0 - no checking (default)
+// 1 - only sanity-check the slice header of src
+// 2 - additionally scan the payload word-by-word
+var cgocheckLevel int32
+
+func init() {
+    if v := os.Getenv("COFFER_CGOCHECK"); v != "" {
+        if lvl, err := strconv.Atoi(v); err == nil {
+            cgocheckLevel = int32(lvl)
+        }
+    }
+}
+
+// Sets the cgocheck validation level used by Write()
+//
+// See cgocheckLevel for what each level does. Overrides
+// whatever COFFER_CGOCHECK was set to at startup.
+func SetCgoCheck(level int) {
+    atomic.StoreInt32(&cgocheckLevel, int32(level))
+}
+
+func cgoCheckLevel() int {
+    return int(atomic.LoadInt32(&cgocheckLevel))
+}
+
+// goRegion describes a range of Go-managed memory that RegisterGoRegion
+// has been told about
+type goRegion struct {
+    base uintptr
+    stop uintptr
+}
+
+var (
+    goRegionsMu sync.RWMutex
+    goRegions   []goRegion
+)
+
+// Tells the cgocheck validator that [base, base+sz) is Go-managed memory.
+//
+// This is the portable alternative to relying on runtime internals to
+// find the Go heap bounds: callers that keep their own Go-backed buffers
+// around (e.g. a []byte that outlives its slice header) can register the
+// underlying array once, and level-2 checking will catch any pointer
+// into it.
+func RegisterGoRegion(base uintptr, sz int) os.Error {
+    if base == uintptr(0) || sz <= 0 {
+        return os.EINVAL
+    }
+    goRegionsMu.Lock()
+    defer goRegionsMu.Unlock()
+    goRegions = append(goRegions, goRegion{base: base, stop: base + uintptr(sz-1)})
+    return nil
+}
+
+// true iff pos falls inside a region registered via RegisterGoRegion
+//
+// runtime.MemStats only reports byte counts (HeapAlloc, HeapSys, ...),
+// not the address range the heap actually occupies, so there is no
+// public, reliable way to derive heap bounds from it; RegisterGoRegion
+// is therefore the actual detection mechanism, not a fallback. Callers
+// that want level >= 1 checking to catch anything must register the Go
+// memory they might pass in
+func inHeap(pos uintptr) bool {
+    if pos == uintptr(0) {
+        return false
+    }
+
+    goRegionsMu.RLock()
+    defer goRegionsMu.RUnlock()
+    for _, r := range goRegions {
+        if pos >= r.base && pos <= r.stop {
+            return true
+        }
+    }
+    return false
+}
+
+// Checks src's slice header only: rejects if &src[0] itself lies in a
+// region registered via RegisterGoRegion (see inHeap). This is level 1.
+func checkSliceHeader(src []uint8) os.Error {
+    if len(src) == 0 {
+        return nil
+    }
+    p := uintptr(unsafe.Pointer(&src[0]))
+    defer runtime.KeepAlive(&src)
+    if inHeap(p) {
+        return ErrGoPointerInC
+    }
+    return nil
+}
+
+// Scans src in uintptr-sized strides starting at &src[0] (which need not
+// be uintptr-aligned) and rejects the first word that looks like a
+// pointer into the Go heap. This is level 2.
+func checkPayloadForGoPointers(src []uint8) os.Error {
+    if err := checkSliceHeader(src); err != nil {
+        return err
+    }
+
+    wordSz := int(unsafe.Sizeof(uintptr(0)))
+    n := len(src) / wordSz
+    if n == 0 {
+        return nil
+    }
+
+    base := unsafe.Pointer(&src[0])
+    for i := 0; i < n; i++ {
+        word := *(*uintptr)(unsafe.Pointer(uintptr(base) + uintptr(i*wordSz)))
+        if inHeap(word) {
+            runtime.KeepAlive(&src)
+            return ErrGoPointerInC
+        }
+    }
+    runtime.KeepAlive(&src)
+    return nil
+}
+
+// Runs the currently configured cgocheck level against src, returning
+// ErrGoPointerInC if it looks unsafe to memmove into C memory.
+func cgoCheck(src []uint8) os.Error {
+    switch cgoCheckLevel() {
+    case 0:
+        return nil
+    case 1:
+        return checkSliceHeader(src)
+    default:
+        return checkPayloadForGoPointers(src)
+    }
+}
@@ -6,6 +6,7 @@ import .  "gonewrong"
 import "unsafe"
 import "fmt"
 import "io"
+import "runtime"
 
 // #include <stdlib.h>
 // #include <string.h>
@@ -217,7 +218,22 @@ func (p *PtrCoffer) Read(dst []uint8) (n int, err os.Error) {
 }
 
 // Will not append but instead stop with EOF at end of range
+//
+// If cgocheck is enabled (see SetCgoCheck), src is validated for
+// embedded Go pointers before it is memmove'd into this coffer's
+// range; use WriteRaw to skip that check
 func (p *PtrCoffer) Write(src []uint8) (n int, err os.Error) {
+    if cgoErr := cgoCheck(src); cgoErr != nil {
+        return 0, cgoErr
+    }
+    return p.WriteRaw(src)
+}
+
+// Identical to Write, but never runs the cgocheck validator
+//
+// Use this when the caller already knows src is pointer-free (e.g. it
+// was read from disk or built out of numeric fields only)
+func (p *PtrCoffer) WriteRaw(src []uint8) (n int, err os.Error) {
 
     // Bail out if EOF was hit before
     if !p.IsOpen() || p.IsEOF() {
@@ -276,23 +292,30 @@ func (p *PtrCoffer) GetBasePtr() uintptr {
 
 // Retrieve seek uintptr
 func (p *PtrCoffer) GetSeekPtr() uintptr {
-	return p.base
+	return p.seek
 }
 
 // Retrieve stop as uintptr
 func (p *PtrCoffer) GetStopPtr() uintptr {
-	return p.base
+	return p.stop
 }
 
 
 // Selfallocating coffer via malloc, frees on Close()
 type MemCoffer struct {
     PtrCoffer
+    allocStack string // set iff leak debugging was enabled at construction time
 }
 
-// Allocate a coffer independent from the go runtime, i.e. 
+// Allocate a coffer independent from the go runtime, i.e.
 // you are responsible for freeing its mem content
 // by calling Close() (You get memory leaks iff you don't)
+//
+// A runtime.SetFinalizer is registered as a safety net: if this coffer
+// is garbage collected while still open, its memory is freed and a
+// warning is logged via leakLogger (see SetLeakLogger, SetLeakDebug).
+// Relying on the finalizer is not a substitute for calling Close();
+// see DisableFinalizer if you want strictly manual lifecycle instead
 func NewMemCoffer(sz int) (coffer Coffer, err os.Error) {
     if sz < 0 {
         return nil, os.EINVAL
@@ -308,14 +331,23 @@ func NewMemCoffer(sz int) (coffer Coffer, err os.Error) {
     cf.base = base_
     cf.seek = seek_
     cf.stop = stop_
+    cf.allocStack = captureAllocStack()
+    runtime.SetFinalizer(cf, finalizeMemCoffer)
     coffer = cf
     return coffer, nil
 }
 
+// Gated on p.base, not p.IsEOF(): a fully drained (but still open)
+// MemCoffer must still C.free its memory and cancel its finalizer on
+// Close()
 func (p *MemCoffer) Close() os.Error {
-    if p.IsEOF() {
-        return os.EOF
+    if p.base == uintptr(0) {
+        return os.EINVAL
     }
+    // Cancel the leak-detecting finalizer first so it never fires
+    // against memory we're about to free ourselves
+    runtime.SetFinalizer(p, nil)
+
     // Free memory in defer
     backup := unsafe.Pointer(p.base)
     defer C.free(backup)
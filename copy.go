@@ -0,0 +1,218 @@
+package coffer
+
+import os "os"
+import "io"
+import "reflect"
+import "sync"
+import "unsafe"
+
+// #include <string.h>
+import "C"
+
+// Size of the bounce buffer used to move bytes between a Coffer and a
+// plain io.Reader/io.Writer that isn't itself a Coffer
+const bounceBufSz = 64 * 1024
+
+var (
+    bounceMu  sync.Mutex
+    bouncePtr unsafe.Pointer
+)
+
+// Lazily allocates the shared C bounce buffer; caller must hold bounceMu
+func ensureBounce() unsafe.Pointer {
+    if bouncePtr == nil {
+        bouncePtr = C.malloc(C.size_t(bounceBufSz))
+    }
+    return bouncePtr
+}
+
+// Wraps a C-owned range as a Go []byte without copying, so it can be
+// handed to an ordinary io.Reader/io.Writer
+func cBufAsSlice(ptr unsafe.Pointer, sz int) []byte {
+    var sl []byte
+    sh := (*reflect.SliceHeader)(unsafe.Pointer(&sl))
+    sh.Data = uintptr(ptr)
+    sh.Len = sz
+    sh.Cap = sz
+    return sl
+}
+
+// Seeks src forward by the n bytes Copy already memmove'd out of it
+//
+// n == avail means src is now exhausted. Seeking straight to the
+// one-past-end offset would panic (PtrCoffer.EnsureContainsOffset caps
+// at Diff()), and gating this on a private seekEOFSetter-style hook only
+// resets PtrCoffer-embedding Coffers - a SyncCoffer implements none of
+// PtrCoffer's internals, so its seek pointer would stay non-zero forever
+// and CopyAll would spin re-copying the same bytes. Instead, land on the
+// last valid byte and re-read it so src's own Read implementation trips
+// its EOF bookkeeping the same way any caller draining it via Read
+// would, regardless of the concrete type
+func advanceRead(src Coffer, n, avail int64) os.Error {
+    if n < avail {
+        _, err := src.Seek(n, 1)
+        return err
+    }
+    if _, err := src.Seek(n-1, 1); err != nil {
+        return err
+    }
+    var scratch [1]byte
+    if _, err := src.Read(scratch[:]); err != nil && err != os.EOF {
+        return err
+    }
+    return nil
+}
+
+// Write-side counterpart of advanceRead
+//
+// dstStart is the pointer Copy's memmove used as dst's starting address,
+// so the byte at dstStart+n-1 is exactly what Copy already wrote there;
+// replaying it through dst's own Write trips dst's EOF bookkeeping the
+// same way advanceRead does for src, without gating on a private hook
+func advanceWrite(dst Coffer, n, avail int64, dstStart unsafe.Pointer) os.Error {
+    if n < avail {
+        _, err := dst.Seek(n, 1)
+        return err
+    }
+    scratch := [1]byte{*(*byte)(unsafe.Pointer(uintptr(dstStart) + uintptr(n-1)))}
+    if _, err := dst.Seek(n-1, 1); err != nil {
+        return err
+    }
+    if _, err := dst.Write(scratch[:]); err != nil && err != os.EOF {
+        return err
+    }
+    return nil
+}
+
+// Copies up to n bytes from src to dst
+//
+// Both dst and src implement GetSeekPtr/GetStopPtr as part of the
+// Coffer interface, so this dispatches a single C.memmove between their
+// current seek positions instead of round-tripping through a Go []byte,
+// then advances both seek pointers by the number of bytes copied
+//
+// returns 0, os.EOF if either side has no bytes left
+func Copy(dst, src Coffer, n int64) (copied int64, err os.Error) {
+    if n <= 0 {
+        return 0, os.EINVAL
+    }
+
+    // seek == 0 is this package's sentinel for "EOF or closed" (see
+    // PtrCoffer.IsEOF/Close); check it before doing pointer arithmetic,
+    // since GetStopPtr() stays non-zero across that transition and would
+    // otherwise make the availability math below look like a huge
+    // positive range instead of "nothing left"
+    srcSeek := src.GetSeekPtr()
+    dstSeek := dst.GetSeekPtr()
+    if srcSeek == uintptr(0) || dstSeek == uintptr(0) {
+        return 0, os.EOF
+    }
+
+    srcAvail := int64(src.GetStopPtr()) - int64(srcSeek) + 1
+    dstAvail := int64(dst.GetStopPtr()) - int64(dstSeek) + 1
+    if srcAvail <= 0 || dstAvail <= 0 {
+        return 0, os.EOF
+    }
+
+    n = min64(n, srcAvail)
+    n = min64(n, dstAvail)
+
+    srcPtr := unsafe.Pointer(srcSeek)
+    dstPtr := unsafe.Pointer(dstSeek)
+    C.memmove(dstPtr, srcPtr, C.size_t(n))
+
+    if serr := advanceRead(src, n, srcAvail); serr != nil {
+        return 0, serr
+    }
+    if derr := advanceWrite(dst, n, dstAvail, dstPtr); derr != nil {
+        return n, derr
+    }
+
+    return n, nil
+}
+
+// Copies from src to dst until src (or dst) is exhausted
+func CopyAll(dst, src Coffer) (copied int64, err os.Error) {
+    for {
+        n, cerr := Copy(dst, src, bounceBufSz)
+        copied += n
+        if cerr != nil {
+            if cerr == os.EOF {
+                return copied, nil
+            }
+            return copied, cerr
+        }
+        if n == 0 {
+            return copied, nil
+        }
+    }
+}
+
+func min64(a, b int64) int64 {
+    if a < b {
+        return a
+    }
+    return b
+}
+
+// io.Copy(coffer, otherCoffer) picks up the memmove fast path via this;
+// falls back to a reused C bounce buffer for a non-Coffer r
+func (p *PtrCoffer) ReadFrom(r io.Reader) (n int64, err os.Error) {
+    if src, ok := r.(Coffer); ok {
+        return CopyAll(p, src)
+    }
+
+    bounceMu.Lock()
+    defer bounceMu.Unlock()
+    buf := cBufAsSlice(ensureBounce(), bounceBufSz)
+
+    for {
+        rn, rerr := r.Read(buf)
+        if rn > 0 {
+            wn, werr := p.WriteRaw(buf[:rn])
+            n += int64(wn)
+            if werr != nil && werr != os.EOF {
+                return n, werr
+            }
+            if werr == os.EOF {
+                return n, nil
+            }
+        }
+        if rerr != nil {
+            if rerr == os.EOF {
+                return n, nil
+            }
+            return n, rerr
+        }
+    }
+}
+
+// Symmetric to ReadFrom: io.Copy(otherCoffer, coffer) picks up the
+// memmove fast path via this; falls back to the shared bounce buffer
+// for a non-Coffer w
+func (p *PtrCoffer) WriteTo(w io.Writer) (n int64, err os.Error) {
+    if dst, ok := w.(Coffer); ok {
+        return CopyAll(dst, p)
+    }
+
+    bounceMu.Lock()
+    defer bounceMu.Unlock()
+    buf := cBufAsSlice(ensureBounce(), bounceBufSz)
+
+    for {
+        rn, rerr := p.Read(buf)
+        if rn > 0 {
+            wn, werr := w.Write(buf[:rn])
+            n += int64(wn)
+            if werr != nil {
+                return n, werr
+            }
+        }
+        if rerr != nil {
+            if rerr == os.EOF {
+                return n, nil
+            }
+            return n, rerr
+        }
+    }
+}
@@ -0,0 +1,86 @@
+package coffer
+
+import os "os"
+import "log"
+import "runtime"
+import "sync/atomic"
+import "unsafe"
+
+// #include <stdlib.h>
+import "C"
+
+// Package-level logger used to warn about MemCoffers collected without
+// ever having been Close()d. Defaults to a plain stderr logger;
+// override with SetLeakLogger
+var leakLogger = log.New(os.Stderr, "coffer: ", log.LstdFlags)
+
+// true iff NewMemCoffer should capture an allocation stack for use in
+// the leak warning; off by default since runtime.Callers is not free
+var leakDebug int32
+
+func init() {
+    if os.Getenv("COFFER_LEAK_DEBUG") != "" {
+        leakDebug = 1
+    }
+}
+
+// Replaces the logger used to warn about leaked (never-Close()d)
+// MemCoffers
+func SetLeakLogger(l *log.Logger) {
+    leakLogger = l
+}
+
+// Enables or disables capturing an allocation stack trace on
+// NewMemCoffer, included in the leak warning if the finalizer ever fires
+func SetLeakDebug(enabled bool) {
+    if enabled {
+        atomic.StoreInt32(&leakDebug, 1)
+    } else {
+        atomic.StoreInt32(&leakDebug, 0)
+    }
+}
+
+// Captures the caller's stack as a printable string, or "" if leak
+// debugging is disabled
+func captureAllocStack() string {
+    if atomic.LoadInt32(&leakDebug) == 0 {
+        return ""
+    }
+    buf := make([]byte, 4096)
+    n := runtime.Stack(buf, false)
+    return string(buf[:n])
+}
+
+// Runs as a runtime finalizer on any MemCoffer that is still holding
+// C memory (i.e. was never Close()d) when the GC collects it. Frees the
+// underlying C memory directly rather than going through Close(), and
+// does so whether or not the coffer was drained to EOF by Read/Write.
+// Also warns via leakLogger, since relying on the finalizer is not
+// something callers should do on purpose
+func finalizeMemCoffer(p *MemCoffer) {
+    if p.base == uintptr(0) {
+        return
+    }
+    base := p.base
+    stack := p.allocStack
+
+    if stack != "" {
+        leakLogger.Printf("MemCoffer %p was garbage collected while still open; allocated at:\n%s", base, stack)
+    } else {
+        leakLogger.Printf("MemCoffer %p was garbage collected while still open (enable SetLeakDebug(true) for an allocation stack)", base)
+    }
+
+    C.free(unsafe.Pointer(base))
+    p.base = uintptr(0)
+    p.seek = uintptr(0)
+    p.stop = uintptr(0)
+}
+
+// Disables the finalizer registered by NewMemCoffer for this coffer
+//
+// Use this if you manage this MemCoffer's lifetime strictly manually
+// and don't want the GC touching it at all, at the cost of a silent
+// leak instead of a logged one if you forget to Close() it
+func (p *MemCoffer) DisableFinalizer() {
+    runtime.SetFinalizer(p, nil)
+}
@@ -0,0 +1,13 @@
+// +build linux
+
+package coffer
+
+// #include <sys/mman.h>
+import "C"
+
+// madvHugePage returns MADV_HUGEPAGE, the transparent-huge-page hint
+// AdviceHugePage maps to on Linux; see mmap_advice_other.go for the
+// platforms where madvise(2) has no equivalent
+func madvHugePage() C.int {
+    return C.MADV_HUGEPAGE
+}
@@ -0,0 +1,13 @@
+// +build !linux,!windows
+
+package coffer
+
+// #include <sys/mman.h>
+import "C"
+
+// Darwin/BSD's madvise(2) has no MADV_HUGEPAGE equivalent, so
+// AdviceHugePage maps to MADV_NORMAL here: no huge pages are requested,
+// and the range is reset to the default paging advice
+func madvHugePage() C.int {
+    return C.MADV_NORMAL
+}
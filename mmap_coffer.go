@@ -0,0 +1,175 @@
+// +build !windows
+
+package coffer
+
+import os "os"
+import .  "gonewrong"
+import "unsafe"
+
+// #include <sys/mman.h>
+// #include <string.h>
+// #include <unistd.h>
+import "C"
+
+// Advice values accepted by MmapCoffer.Advise, mirroring madvise(2)
+type Advice int
+
+const (
+    AdviceSequential Advice = iota
+    AdviceRandom
+    AdviceDontNeed
+    AdviceHugePage
+)
+
+func (a Advice) toC() C.int {
+    switch a {
+    case AdviceSequential:
+        return C.MADV_SEQUENTIAL
+    case AdviceRandom:
+        return C.MADV_RANDOM
+    case AdviceDontNeed:
+        return C.MADV_DONTNEED
+    case AdviceHugePage:
+        return madvHugePage()
+    default:
+        return C.MADV_NORMAL
+    }
+}
+
+// Options for NewMmapCoffer
+//
+// If File is non-nil, the reservation is backed by that file (MAP_SHARED)
+// starting at FileOffset instead of anonymous memory; the caller retains
+// ownership of File and must not close it before the coffer
+type MmapOptions struct {
+    File       *os.File
+    FileOffset int64
+}
+
+// A Coffer backed by a raw mmap(2) reservation instead of malloc
+//
+// The whole range is reserved PROT_NONE up front; callers must Commit()
+// the sub-ranges they intend to touch before reading or writing them,
+// mirroring the reserve/commit split the Go runtime itself uses
+// (sysReserve/sysMap) to manage very large address ranges cheaply
+type MmapCoffer struct {
+    PtrCoffer
+    fileBacked bool
+}
+
+// Reserves sz bytes of address space and returns a Coffer over it
+//
+// The reservation starts out inaccessible; call Commit() before Read()
+// or Write() touch a given sub-range, or that access will fault
+func NewMmapCoffer(sz int, opts MmapOptions) (coffer Coffer, err os.Error) {
+    if sz <= 0 {
+        return nil, os.EINVAL
+    }
+
+    flags := C.MAP_PRIVATE | C.MAP_ANON
+    fd := C.int(-1)
+    fileBacked := false
+    if opts.File != nil {
+        flags = C.MAP_SHARED
+        fd = C.int(opts.File.Fd())
+        fileBacked = true
+    }
+
+    addr, cerr := C.mmap(nil, C.size_t(sz), C.PROT_NONE, C.int(flags), fd, C.off_t(opts.FileOffset))
+    if uintptr(addr) == ^uintptr(0) /* MAP_FAILED */ {
+        return nil, os.Errno(GetCErrno())
+    }
+    _ = cerr
+
+    base_ := uintptr(addr)
+    cf := new(MmapCoffer)
+    cf.base = base_
+    cf.seek = base_
+    cf.stop = base_ + uintptr(sz-1)
+    cf.fileBacked = fileBacked
+    return cf, nil
+}
+
+// The system page size, used to round Commit/Advise ranges down to the
+// boundary mprotect(2)/madvise(2) require of addr
+func pageSize() int64 {
+    return int64(C.getpagesize())
+}
+
+// Rounds [offset, offset+length) down to a page-aligned [addr, addr+len)
+// covering the same bytes, so callers can pass an arbitrary sub-range
+// without knowing the page size themselves
+//
+// The caller must already have checked offset+length against the
+// reservation's bounds; widening offset down by at most a page can't
+// push the aligned range past what was already validated
+func pageAlign(offset, length int64) (alignedOffset, alignedLength int64) {
+    ps := pageSize()
+    alignedOffset = offset - offset%ps
+    alignedLength = length + (offset - alignedOffset)
+    return
+}
+
+// Upgrades [offset, offset+length) within this reservation to
+// PROT_READ|PROT_WRITE so it can be read or written
+//
+// offset need not be page-aligned; the underlying mprotect(2) call is
+// widened down to the enclosing page boundary
+func (p *MmapCoffer) Commit(offset, length int64) os.Error {
+    if !p.IsOpen() {
+        return os.EINVAL
+    }
+    if offset < 0 || length <= 0 || offset+length > int64(p.Diff())+1 {
+        return os.EINVAL
+    }
+    alignedOffset, alignedLength := pageAlign(offset, length)
+    addr := unsafe.Pointer(p.base + uintptr(alignedOffset))
+    rc := C.mprotect(addr, C.size_t(alignedLength), C.PROT_READ|C.PROT_WRITE)
+    if rc != 0 {
+        return os.Errno(GetCErrno())
+    }
+    return nil
+}
+
+// Wraps madvise(2) for [offset, offset+length) within this reservation
+//
+// offset need not be page-aligned; the underlying madvise(2) call is
+// widened down to the enclosing page boundary
+func (p *MmapCoffer) Advise(offset, length int64, advice Advice) os.Error {
+    if !p.IsOpen() {
+        return os.EINVAL
+    }
+    if offset < 0 || length <= 0 || offset+length > int64(p.Diff())+1 {
+        return os.EINVAL
+    }
+    alignedOffset, alignedLength := pageAlign(offset, length)
+    addr := unsafe.Pointer(p.base + uintptr(alignedOffset))
+    rc := C.madvise(addr, C.size_t(alignedLength), advice.toC())
+    if rc != 0 {
+        return os.Errno(GetCErrno())
+    }
+    return nil
+}
+
+// Unmaps the whole reservation
+//
+// Gated on p.base, not p.IsEOF(): a fully drained (but still open)
+// MmapCoffer must still munmap its reservation on Close()
+func (p *MmapCoffer) Close() os.Error {
+    if p.base == uintptr(0) {
+        return os.EINVAL
+    }
+    base_ := p.base
+    sz := p.Diff() + 1
+
+    // Zero ptrs to avoid any lingering harm
+    p.base = uintptr(0)
+    p.seek = uintptr(0)
+    p.stop = uintptr(0)
+
+    rc := C.munmap(unsafe.Pointer(base_), C.size_t(sz))
+    if rc != 0 {
+        return os.Errno(GetCErrno())
+    }
+    return nil
+}
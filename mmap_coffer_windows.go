@@ -0,0 +1,117 @@
+// +build windows
+
+package coffer
+
+import os "os"
+import "syscall"
+
+const (
+    mem_RESERVE = 0x00002000
+    mem_COMMIT  = 0x00001000
+    mem_RELEASE = 0x00008000
+
+    page_NOACCESS  = 0x01
+    page_READWRITE = 0x04
+)
+
+// Advice values accepted by MmapCoffer.Advise
+//
+// Windows has no direct madvise equivalent; Advise is a no-op here and
+// only kept so callers can share code with the Unix build
+type Advice int
+
+const (
+    AdviceSequential Advice = iota
+    AdviceRandom
+    AdviceDontNeed
+    AdviceHugePage
+)
+
+// Options for NewMmapCoffer
+//
+// File-backing is not implemented on the Windows build
+type MmapOptions struct {
+    File       *os.File
+    FileOffset int64
+}
+
+var (
+    modkernel32        = syscall.NewLazyDLL("kernel32.dll")
+    procVirtualAlloc   = modkernel32.NewProc("VirtualAlloc")
+    procVirtualFree    = modkernel32.NewProc("VirtualFree")
+    procVirtualProtect = modkernel32.NewProc("VirtualProtect")
+)
+
+// A Coffer backed by a raw VirtualAlloc reservation instead of malloc
+//
+// The whole range is reserved MEM_RESERVE up front; callers must
+// Commit() the sub-ranges they intend to touch before reading or
+// writing them
+type MmapCoffer struct {
+    PtrCoffer
+}
+
+// Reserves sz bytes of address space and returns a Coffer over it
+func NewMmapCoffer(sz int, opts MmapOptions) (coffer Coffer, err os.Error) {
+    if sz <= 0 {
+        return nil, os.EINVAL
+    }
+
+    addr, _, callErr := procVirtualAlloc.Call(0, uintptr(sz), mem_RESERVE, page_NOACCESS)
+    if addr == 0 {
+        return nil, os.NewError(callErr.Error())
+    }
+
+    cf := new(MmapCoffer)
+    cf.base = addr
+    cf.seek = addr
+    cf.stop = addr + uintptr(sz-1)
+    return cf, nil
+}
+
+// Commits [offset, offset+length) within this reservation and makes it
+// readable/writable
+func (p *MmapCoffer) Commit(offset, length int64) os.Error {
+    if !p.IsOpen() {
+        return os.EINVAL
+    }
+    if offset < 0 || length <= 0 || offset+length > int64(p.Diff())+1 {
+        return os.EINVAL
+    }
+    addr := p.base + uintptr(offset)
+    ret, _, callErr := procVirtualAlloc.Call(addr, uintptr(length), mem_COMMIT, page_READWRITE)
+    if ret == 0 {
+        return os.NewError(callErr.Error())
+    }
+    return nil
+}
+
+// No-op on Windows; kept so callers can share code with the Unix build
+func (p *MmapCoffer) Advise(offset, length int64, advice Advice) os.Error {
+    if !p.IsOpen() {
+        return os.EINVAL
+    }
+    return nil
+}
+
+// Releases the whole reservation
+//
+// Gated on p.base, not p.IsEOF(): a fully drained (but still open)
+// MmapCoffer must still VirtualFree its reservation on Close()
+func (p *MmapCoffer) Close() os.Error {
+    if p.base == uintptr(0) {
+        return os.EINVAL
+    }
+    base_ := p.base
+
+    // Zero ptrs to avoid any lingering harm
+    p.base = uintptr(0)
+    p.seek = uintptr(0)
+    p.stop = uintptr(0)
+
+    ret, _, callErr := procVirtualFree.Call(base_, 0, mem_RELEASE)
+    if ret == 0 {
+        return os.NewError(callErr.Error())
+    }
+    return nil
+}
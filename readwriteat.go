@@ -0,0 +1,67 @@
+package coffer
+
+import os "os"
+import "unsafe"
+
+// #include <string.h>
+import "C"
+
+// Reads len(dst) bytes starting at off without touching the shared seek
+// position, so it is safe to call concurrently with other ReadAt calls;
+// it does not synchronize with anything that writes the same bytes
+// (Write/WriteAt on this or any other coffer over the same range)
+//
+// returns the number of bytes copied and os.EOF iff off+len(dst) runs
+// past the end of the managed range
+func (p *PtrCoffer) ReadAt(dst []uint8, off int64) (n int, err os.Error) {
+    if !p.IsOpen() {
+        return 0, os.EINVAL
+    }
+    if off < 0 || off > int64(p.Diff()) {
+        return 0, os.EINVAL
+    }
+    if len(dst) == 0 {
+        return 0, os.EINVAL
+    }
+
+    avail := int64(p.Diff()) - off + 1
+    n64 := int64(len(dst))
+    srcPtr := unsafe.Pointer(p.base + uintptr(off))
+    if avail < n64 {
+        C.memmove(unsafe.Pointer(&dst[0]), srcPtr, C.size_t(avail))
+        return int(avail), os.EOF
+    }
+    C.memmove(unsafe.Pointer(&dst[0]), srcPtr, C.size_t(n64))
+    return len(dst), nil
+}
+
+// Writes len(src) bytes starting at off without touching the shared
+// seek position; safe to call concurrently with other WriteAt calls
+// over disjoint ranges
+//
+// Subject to the same cgocheck validation as Write
+func (p *PtrCoffer) WriteAt(src []uint8, off int64) (n int, err os.Error) {
+    if cgoErr := cgoCheck(src); cgoErr != nil {
+        return 0, cgoErr
+    }
+
+    if !p.IsOpen() {
+        return 0, os.EINVAL
+    }
+    if off < 0 || off > int64(p.Diff()) {
+        return 0, os.EINVAL
+    }
+    if len(src) == 0 {
+        return 0, os.EINVAL
+    }
+
+    avail := int64(p.Diff()) - off + 1
+    n64 := int64(len(src))
+    dstPtr := unsafe.Pointer(p.base + uintptr(off))
+    if avail < n64 {
+        C.memmove(dstPtr, unsafe.Pointer(&src[0]), C.size_t(avail))
+        return int(avail), os.EOF
+    }
+    C.memmove(dstPtr, unsafe.Pointer(&src[0]), C.size_t(n64))
+    return len(src), nil
+}
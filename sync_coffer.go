@@ -0,0 +1,121 @@
+package coffer
+
+import os "os"
+import "sync"
+import "sync/atomic"
+import "unsafe"
+
+// A Coffer wrapper that guards every Read/Write/Seek/Close with an
+// RWMutex, for callers who want to share a single Coffer across
+// goroutines without hand-rolling the locking themselves
+//
+// The wrapped Coffer is otherwise used as-is; SyncCoffer adds no
+// buffering or copying of its own
+type SyncCoffer struct {
+    mu sync.RWMutex
+    c  Coffer
+}
+
+// Wraps c so its Read/Write/Seek/Close (and the atomic helpers below)
+// are safe to call from multiple goroutines
+func NewSyncCoffer(c Coffer) Coffer {
+    return &SyncCoffer{c: c}
+}
+
+func (s *SyncCoffer) Read(dst []uint8) (n int, err os.Error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.c.Read(dst)
+}
+
+func (s *SyncCoffer) Write(src []uint8) (n int, err os.Error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.c.Write(src)
+}
+
+func (s *SyncCoffer) Seek(offset int64, whence int) (ret int64, err os.Error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.c.Seek(offset, whence)
+}
+
+func (s *SyncCoffer) Close() os.Error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.c.Close()
+}
+
+func (s *SyncCoffer) GetBasePtr() uintptr {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.c.GetBasePtr()
+}
+
+func (s *SyncCoffer) GetSeekPtr() uintptr {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.c.GetSeekPtr()
+}
+
+func (s *SyncCoffer) GetStopPtr() uintptr {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.c.GetStopPtr()
+}
+
+// panic(os.EINVAL) iff base+off isn't 8-byte aligned, or [off, off+8)
+// doesn't fit inside the wrapped Coffer's managed range; caller must
+// hold s.mu
+//
+// sync/atomic only guarantees correct behavior on 8-byte-aligned words
+// (see the sync/atomic package docs); an unaligned word can silently
+// misbehave on amd64 and panic outright on 32-bit archs, so this is
+// checked even though the range check alone would let it through. off
+// being a multiple of 8 isn't enough on its own: the wrapped Coffer's
+// base pointer is whatever malloc/mmap handed back and isn't guaranteed
+// to be 8-aligned itself, so the absolute address is what has to line up
+func (s *SyncCoffer) wordPtr(off int64) *uint64 {
+    wordSz := int64(unsafe.Sizeof(uint64(0)))
+    base := s.c.GetBasePtr()
+    stop := s.c.GetStopPtr()
+    if off < 0 || uintptr(off)+unsafe.Sizeof(uint64(0)) > stop-base+1 {
+        panic(os.EINVAL)
+    }
+    if (base+uintptr(off))%uintptr(wordSz) != 0 {
+        panic(os.EINVAL)
+    }
+    return (*uint64)(unsafe.Pointer(base + uintptr(off)))
+}
+
+// Atomically loads the uint64 stored at byte offset off in the wrapped
+// Coffer's range
+//
+// panics with os.EINVAL iff [off, off+8) falls outside the range or
+// base+off isn't 8-byte aligned
+func (s *SyncCoffer) AtomicLoadUint64(off int64) uint64 {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return atomic.LoadUint64(s.wordPtr(off))
+}
+
+// Atomically stores val at byte offset off in the wrapped Coffer's range
+//
+// panics with os.EINVAL iff [off, off+8) falls outside the range or
+// base+off isn't 8-byte aligned
+func (s *SyncCoffer) AtomicStoreUint64(off int64, val uint64) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    atomic.StoreUint64(s.wordPtr(off), val)
+}
+
+// Atomically compares-and-swaps the uint64 at byte offset off in the
+// wrapped Coffer's range
+//
+// panics with os.EINVAL iff [off, off+8) falls outside the range or
+// base+off isn't 8-byte aligned
+func (s *SyncCoffer) CompareAndSwapUint64(off int64, old, new uint64) bool {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return atomic.CompareAndSwapUint64(s.wordPtr(off), old, new)
+}